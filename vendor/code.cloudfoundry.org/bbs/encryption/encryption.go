@@ -0,0 +1,209 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+)
+
+type Key interface {
+	Label() string
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(cipherText []byte) ([]byte, error)
+
+	// MACKey returns the raw secret material backing this key, for callers
+	// that need to authenticate data (e.g. an HMAC) alongside encrypting it.
+	MACKey() []byte
+}
+
+type KeyManager interface {
+	EncryptionKey() (Key, error)
+	DecryptionKey(label string) (Key, error)
+}
+
+type Cryptor interface {
+	Encrypt(plaintext []byte) ([]byte, string, error)
+	Decrypt(cipherText []byte, label string) ([]byte, error)
+}
+
+type cryptor struct {
+	keyManager KeyManager
+	reader     io.Reader
+}
+
+func NewCryptor(keyManager KeyManager, reader io.Reader) Cryptor {
+	return &cryptor{keyManager: keyManager, reader: reader}
+}
+
+func (c *cryptor) Encrypt(plaintext []byte) ([]byte, string, error) {
+	key, err := c.keyManager.EncryptionKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	cipherText, err := key.Encrypt(plaintext)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cipherText, key.Label(), nil
+}
+
+func (c *cryptor) Decrypt(cipherText []byte, label string) ([]byte, error) {
+	key, err := c.keyManager.DecryptionKey(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Decrypt(cipherText)
+}
+
+type aesGCMKey struct {
+	label      string
+	passphrase []byte
+}
+
+func NewKey(label, passphrase string) Key {
+	sum := sha256.Sum256([]byte(passphrase))
+	return &aesGCMKey{label: label, passphrase: sum[:]}
+}
+
+func (k *aesGCMKey) Label() string {
+	return k.label
+}
+
+func (k *aesGCMKey) MACKey() []byte {
+	return k.passphrase
+}
+
+func (k *aesGCMKey) Encrypt(plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(k.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *aesGCMKey) Decrypt(cipherText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(k.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(cipherText) < nonceSize {
+		return nil, errors.New("ciphertext-too-short")
+	}
+
+	nonce, sealed := cipherText[:nonceSize], cipherText[nonceSize:]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func HMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+type EncryptionFlags struct {
+	ActiveKeyLabel string
+	EncryptionKeys map[string]string
+}
+
+func AddEncryptionFlags(flagSet *flag.FlagSet) *EncryptionFlags {
+	ef := &EncryptionFlags{
+		EncryptionKeys: map[string]string{},
+	}
+
+	flagSet.StringVar(
+		&ef.ActiveKeyLabel,
+		"activeKeyLabel",
+		"",
+		"Label of the encryption key to use for encrypting new data",
+	)
+
+	flagSet.Var(
+		(*encryptionKeyValue)(&ef.EncryptionKeys),
+		"encryptionKey",
+		"A label:passphrase pair used to decrypt data (may be repeated)",
+	)
+
+	return ef
+}
+
+type encryptionKeyValue map[string]string
+
+func (v *encryptionKeyValue) String() string {
+	return ""
+}
+
+func (v *encryptionKeyValue) Set(s string) error {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return errors.New("encryptionKey must be in the form label:passphrase")
+	}
+
+	(*v)[parts[0]] = parts[1]
+	return nil
+}
+
+func (ef *EncryptionFlags) Parse() (KeyManager, error) {
+	if ef.ActiveKeyLabel == "" {
+		return nil, errors.New("activeKeyLabel is required")
+	}
+
+	_, ok := ef.EncryptionKeys[ef.ActiveKeyLabel]
+	if !ok {
+		return nil, errors.New("activeKeyLabel must have a matching encryptionKey")
+	}
+
+	keys := map[string]Key{}
+	for label, phrase := range ef.EncryptionKeys {
+		keys[label] = NewKey(label, phrase)
+	}
+
+	return &keyManager{
+		activeKey: keys[ef.ActiveKeyLabel],
+		keys:      keys,
+	}, nil
+}
+
+type keyManager struct {
+	activeKey Key
+	keys      map[string]Key
+}
+
+func (k *keyManager) EncryptionKey() (Key, error) {
+	return k.activeKey, nil
+}
+
+func (k *keyManager) DecryptionKey(label string) (Key, error) {
+	key, ok := k.keys[label]
+	if !ok {
+		return nil, errors.New("unknown-key-label: " + label)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCryptorEncryptDecryptRoundTrip(t *testing.T) {
+	km := &keyManager{
+		activeKey: NewKey("label1", "passphrase-1"),
+		keys:      map[string]Key{"label1": NewKey("label1", "passphrase-1")},
+	}
+	cryptor := NewCryptor(km, rand.Reader)
+
+	cipherText, label, err := cryptor.Encrypt([]byte("super-secret-value"))
+	if err != nil {
+		t.Fatalf("encrypt: %s", err)
+	}
+	if label != "label1" {
+		t.Fatalf("expected label1, got %s", label)
+	}
+
+	plainText, err := cryptor.Decrypt(cipherText, label)
+	if err != nil {
+		t.Fatalf("decrypt: %s", err)
+	}
+	if string(plainText) != "super-secret-value" {
+		t.Errorf("expected round-tripped plaintext, got %q", plainText)
+	}
+}
+
+func TestCryptorDecryptOldKeyDuringRotation(t *testing.T) {
+	oldKey := NewKey("label1", "passphrase-1")
+	newKey := NewKey("label2", "passphrase-2")
+	km := &keyManager{
+		activeKey: newKey,
+		keys:      map[string]Key{"label1": oldKey, "label2": newKey},
+	}
+	cryptor := NewCryptor(km, rand.Reader)
+
+	// Data encrypted under the previous active key must still decrypt once
+	// a new key becomes active, the whole point of a rotation window.
+	oldCipherText, err := oldKey.Encrypt([]byte("pre-rotation-value"))
+	if err != nil {
+		t.Fatalf("encrypt with old key: %s", err)
+	}
+
+	plainText, err := cryptor.Decrypt(oldCipherText, "label1")
+	if err != nil {
+		t.Fatalf("decrypt with retired key: %s", err)
+	}
+	if string(plainText) != "pre-rotation-value" {
+		t.Errorf("expected pre-rotation plaintext, got %q", plainText)
+	}
+
+	// New encryptions land under the active key.
+	newCipherText, label, err := cryptor.Encrypt([]byte("post-rotation-value"))
+	if err != nil {
+		t.Fatalf("encrypt with active key: %s", err)
+	}
+	if label != "label2" {
+		t.Fatalf("expected new encryptions to use label2, got %s", label)
+	}
+	if bytes.Equal(newCipherText, oldCipherText) {
+		t.Error("expected re-encryption to produce different ciphertext")
+	}
+}
+
+func TestCryptorDecryptUnknownLabel(t *testing.T) {
+	km := &keyManager{
+		activeKey: NewKey("label1", "passphrase-1"),
+		keys:      map[string]Key{"label1": NewKey("label1", "passphrase-1")},
+	}
+	cryptor := NewCryptor(km, rand.Reader)
+
+	if _, err := cryptor.Decrypt([]byte("irrelevant"), "unknown-label"); err == nil {
+		t.Error("expected decrypting under an unregistered key label to fail")
+	}
+}
+
+func TestKeyMACKeyAuthenticatesData(t *testing.T) {
+	key := NewKey("label1", "passphrase-1")
+
+	mac := HMAC(key.MACKey(), []byte("owner-a"))
+	if !bytes.Equal(mac, HMAC(key.MACKey(), []byte("owner-a"))) {
+		t.Error("expected HMAC over the same key material and data to be deterministic")
+	}
+	if bytes.Equal(mac, HMAC(key.MACKey(), []byte("owner-b"))) {
+		t.Error("expected HMAC to change when the authenticated data changes")
+	}
+
+	otherKey := NewKey("label2", "different-passphrase")
+	if bytes.Equal(mac, HMAC(otherKey.MACKey(), []byte("owner-a"))) {
+		t.Error("expected HMAC to change when the key material changes")
+	}
+}
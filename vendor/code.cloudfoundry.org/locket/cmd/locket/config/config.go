@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/durationjson"
+	"code.cloudfoundry.org/lager/lagerflags"
+	uaaconfig "code.cloudfoundry.org/uaa-go-client/config"
+)
+
+type LocketConfig struct {
+	lagerflags.LagerConfig
+
+	CaFile   string `json:"ca_file"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	ListenAddress string `json:"listen_address"`
+	DebugAddress  string `json:"debug_address,omitempty"`
+
+	DatabaseDriver             string `json:"database_driver,omitempty"`
+	DatabaseConnectionString   string `json:"database_connection_string,omitempty"`
+	SQLCACertFile              string `json:"sql_ca_cert_file,omitempty"`
+	SQLClientCertFile          string `json:"sql_client_cert_file,omitempty"`
+	SQLClientKeyFile           string `json:"sql_client_key_file,omitempty"`
+	SQLTLSMode                 string `json:"sql_tls_mode,omitempty"`
+	SQLTLSSkipVerify           bool   `json:"sql_tls_skip_verify,omitempty"`
+	MaxOpenDatabaseConnections int    `json:"max_open_database_connections,omitempty"`
+
+	ConsulCluster                   string `json:"consul_cluster,omitempty"`
+	EnableConsulServiceRegistration bool   `json:"enable_consul_service_registration,omitempty"`
+
+	EnableHAMode      bool                  `json:"enable_ha_mode,omitempty"`
+	LockTTL           durationjson.Duration `json:"lock_ttl,omitempty"`
+	LockRetryInterval durationjson.Duration `json:"lock_retry_interval,omitempty"`
+
+	ReportInterval    durationjson.Duration `json:"report_interval,omitempty"`
+	LoggregatorConfig loggingclient.Config  `json:"loggregator"`
+
+	UAAConfig    uaaconfig.Config `json:"uaa_config"`
+	AuthDisabled bool             `json:"auth_disabled,omitempty"`
+
+	ActiveKeyLabel string            `json:"active_key_label"`
+	EncryptionKeys map[string]string `json:"encryption_keys"`
+
+	ETCDOptions ETCDOptions `json:"etcd_options,omitempty"`
+
+	MetricsEmitter string `json:"metrics_emitter,omitempty"`
+	DataDogAPIKey  string `json:"datadog_api_key,omitempty"`
+	DataDogAppKey  string `json:"datadog_app_key,omitempty"`
+	MetricPrefix   string `json:"metric_prefix,omitempty"`
+}
+
+// ETCDOptions configures the etcd-v3 LockDB backend, selected by setting
+// DatabaseDriver to "etcd". It mirrors the TLS fields already used for the
+// mTLS listener since both ultimately go through cfhttp.NewTLSConfig.
+type ETCDOptions struct {
+	ClusterUrls            []string `json:"cluster_urls,omitempty"`
+	CertFile               string   `json:"cert_file,omitempty"`
+	KeyFile                string   `json:"key_file,omitempty"`
+	CAFile                 string   `json:"ca_file,omitempty"`
+	ClientSessionCacheSize int      `json:"client_session_cache_size,omitempty"`
+}
+
+func DefaultLocketConfig() LocketConfig {
+	return LocketConfig{
+		LagerConfig:                lagerflags.DefaultLagerConfig(),
+		ListenAddress:              "0.0.0.0:8891",
+		DatabaseDriver:             "mysql",
+		MaxOpenDatabaseConnections: 200,
+		ReportInterval:             durationjson.Duration(60),
+		MetricsEmitter:             "loggregator",
+		LockTTL:                    durationjson.Duration(15 * time.Second),
+		LockRetryInterval:          durationjson.Duration(5 * time.Second),
+	}
+}
+
+func NewLocketConfig(configPath string) (LocketConfig, error) {
+	cfg := DefaultLocketConfig()
+
+	configFile, err := os.Open(configPath)
+	if err != nil {
+		return LocketConfig{}, err
+	}
+	defer configFile.Close()
+
+	decoder := json.NewDecoder(configFile)
+	err = decoder.Decode(&cfg)
+	if err != nil {
+		return LocketConfig{}, err
+	}
+
+	return cfg, nil
+}
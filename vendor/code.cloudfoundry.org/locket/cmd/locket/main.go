@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
@@ -9,6 +11,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"regexp"
 	"time"
 
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
@@ -21,6 +24,7 @@ import (
 	"github.com/tedsuo/ifrit/sigmon"
 
 	"code.cloudfoundry.org/bbs/db/sqldb/helpers"
+	"code.cloudfoundry.org/bbs/encryption"
 	"code.cloudfoundry.org/bbs/guidprovider"
 	"code.cloudfoundry.org/cfhttp"
 	"code.cloudfoundry.org/clock"
@@ -35,6 +39,7 @@ import (
 	"code.cloudfoundry.org/locket/grpcserver"
 	"code.cloudfoundry.org/locket/handlers"
 	"code.cloudfoundry.org/locket/metrics"
+	uaa_client "code.cloudfoundry.org/uaa-go-client"
 )
 
 var configFilePath = flag.String(
@@ -44,6 +49,13 @@ var configFilePath = flag.String(
 )
 
 func main() {
+	args := os.Args
+	if len(args) > 1 && args[1] == "re-encrypt" {
+		flag.CommandLine.Parse(args[2:])
+		runReEncrypt()
+		return
+	}
+
 	flag.Parse()
 
 	cfg, err := config.NewLocketConfig(*configFilePath)
@@ -53,45 +65,32 @@ func main() {
 
 	logger, reconfigurableSink := lagerflags.NewFromConfig("locket", cfg.LagerConfig)
 
-	metronClient, err := initializeMetron(logger, cfg)
+	keyManager, err := encryptionFlagsFromConfig(cfg).Parse()
 	if err != nil {
-		logger.Error("failed-to-initialize-metron-client", err)
-		os.Exit(1)
+		logger.Fatal("invalid-encryption-keys", err)
 	}
+	cryptor := encryption.NewCryptor(keyManager, rand.Reader)
 
 	clock := clock.NewClock()
 
-	connectionString := appendExtraConnectionStringParam(
-		logger,
-		cfg.DatabaseDriver,
-		cfg.DatabaseConnectionString,
-		cfg.SQLCACertFile,
-	)
-
-	sqlConn, err := sql.Open(cfg.DatabaseDriver, connectionString)
+	metronClient, err := initializeMetron(logger, cfg)
 	if err != nil {
-		logger.Fatal("failed-to-open-sql", err)
+		logger.Error("failed-to-initialize-metron-client", err)
+		os.Exit(1)
 	}
-	defer sqlConn.Close()
 
-	sqlConn.SetMaxIdleConns(cfg.MaxOpenDatabaseConnections)
-	sqlConn.SetMaxOpenConns(cfg.MaxOpenDatabaseConnections)
-
-	err = sqlConn.Ping()
+	emitter, err := initializeEmitter(logger, clock, cfg, metronClient)
 	if err != nil {
-		logger.Fatal("sql-failed-to-connect", err)
+		logger.Error("failed-to-initialize-metrics-emitter", err)
+		os.Exit(1)
 	}
 
-	dbMonitor := helpers.NewQueryMonitor()
-	monitoredDB := helpers.NewMonitoredDB(sqlConn, dbMonitor)
-
-	sqlDB := db.NewSQLDB(
-		monitoredDB,
-		cfg.DatabaseDriver,
-		guidprovider.DefaultGuidProvider,
-	)
+	lockDB, dbMetricsNotifier, err := initializeLockDB(logger, cfg, clock, emitter, keyManager, cryptor)
+	if err != nil {
+		logger.Fatal("failed-to-initialize-lock-db", err)
+	}
 
-	err = sqlDB.CreateLockTable(logger)
+	err = lockDB.CreateLockTable(logger)
 	if err != nil {
 		logger.Fatal("failed-to-create-lock-table", err)
 	}
@@ -111,14 +110,27 @@ func main() {
 		logger.Fatal("invalid-tls-config", err)
 	}
 
-	lockMetricsNotifier := metrics.NewLockMetricsNotifier(logger, clock, metronClient, time.Duration(cfg.ReportInterval), sqlDB)
-	dbMetricsNotifier := metrics.NewDBMetricsNotifier(logger, clock, metronClient, time.Duration(cfg.ReportInterval), sqlDB, dbMonitor)
-	requestNotifier := metrics.NewRequestMetricsNotifier(logger, clock, metronClient, time.Duration(cfg.ReportInterval))
-	lockPick := expiration.NewLockPick(sqlDB, clock, metronClient)
-	burglar := expiration.NewBurglar(logger, sqlDB, lockPick, clock, locket.RetryInterval, metronClient)
+	lockMetricsNotifier := metrics.NewLockMetricsNotifier(logger, clock, emitter, time.Duration(cfg.ReportInterval), lockDB)
+	requestNotifier := metrics.NewRequestMetricsNotifier(logger, clock, emitter, time.Duration(cfg.ReportInterval))
+
+	var lockPick expiration.LockPick
+	var burglar ifrit.Runner
+	if cfg.DatabaseDriver == "etcd" {
+		lockPick = expiration.NewNoopLockPick()
+		burglar = expiration.NewNoopBurglar(logger)
+	} else {
+		lockPick = expiration.NewLockPick(lockDB, clock, emitter)
+		burglar = expiration.NewBurglar(logger, lockDB, lockPick, clock, locket.RetryInterval, emitter, cryptor)
+	}
+
+	tokenValidator, err := initializeTokenValidator(logger, cfg, clock)
+	if err != nil {
+		logger.Fatal("failed-to-initialize-token-validator", err)
+	}
+
 	exitCh := make(chan struct{})
-	handler := handlers.NewLocketHandler(logger, sqlDB, lockPick, requestNotifier, exitCh)
-	server := grpcserver.NewGRPCServer(logger, cfg.ListenAddress, tlsConfig, handler)
+	handler := handlers.NewLocketHandler(logger, lockDB, lockPick, requestNotifier, tokenValidator, exitCh)
+	server := grpcserver.NewGRPCServer(logger, cfg.ListenAddress, tlsConfig, handler, handler.UnaryInterceptor)
 
 	members := grouper.Members{
 		{"server", server},
@@ -128,22 +140,39 @@ func main() {
 		{"request-metrics-notifier", requestNotifier},
 	}
 
-	if cfg.EnableConsulServiceRegistration {
-		consulClient, err := consuladapter.NewClientFromUrl(cfg.ConsulCluster)
+	var consulClient consuladapter.Client
+	if cfg.EnableConsulServiceRegistration || cfg.EnableHAMode {
+		consulClient, err = consuladapter.NewClientFromUrl(cfg.ConsulCluster)
 		if err != nil {
 			logger.Fatal("new-consul-client-failed", err)
 		}
+	}
+
+	if cfg.EnableConsulServiceRegistration {
 		registrationRunner := initializeRegistrationRunner(logger, consulClient, portNum, clock)
 		members = append(members, grouper.Member{"registration-runner", registrationRunner})
 	}
 
+	// With EnableHAMode set, everything above only ever runs on the instance
+	// holding the leader lock, so two instances never serve the same backend
+	// at once. Without it, every instance runs it unconditionally.
+	var servingRunner ifrit.Runner = grouper.NewOrdered(os.Interrupt, members)
+	if cfg.EnableHAMode {
+		lock := locket.NewLock(logger, consulClient, guidprovider.DefaultGuidProvider, locket.LeaderKey, clock, time.Duration(cfg.LockRetryInterval), time.Duration(cfg.LockTTL))
+		servingRunner = locket.NewHARunner(logger, lock, servingRunner, clock, emitter)
+	}
+
+	topLevelMembers := grouper.Members{
+		{"locket", servingRunner},
+	}
+
 	if cfg.DebugAddress != "" {
-		members = append(grouper.Members{
+		topLevelMembers = append(grouper.Members{
 			{"debug-server", debugserver.Runner(cfg.DebugAddress, reconfigurableSink)},
-		}, members...)
+		}, topLevelMembers...)
 	}
 
-	group := grouper.NewOrdered(os.Interrupt, members)
+	group := grouper.NewOrdered(os.Interrupt, topLevelMembers)
 	monitor := ifrit.Invoke(sigmon.New(group))
 
 	logger.Info("started")
@@ -175,10 +204,111 @@ func initializeMetron(logger lager.Logger, locketConfig config.LocketConfig) (lo
 	return client, nil
 }
 
-func appendExtraConnectionStringParam(logger lager.Logger, driverName, databaseConnectionString, sqlCACertFile string) string {
+func initializeEmitter(
+	logger lager.Logger,
+	clock clock.Clock,
+	cfg config.LocketConfig,
+	metronClient loggingclient.IngressClient,
+) (metrics.Emitter, error) {
+	loggregatorEmitter := metrics.NewLoggregatorEmitter(metronClient)
+
+	switch cfg.MetricsEmitter {
+	case "datadog":
+		return metrics.NewDatadogEmitter(logger, clock, time.Duration(cfg.ReportInterval), cfg.DataDogAPIKey, cfg.DataDogAppKey, cfg.MetricPrefix), nil
+	case "both":
+		datadogEmitter := metrics.NewDatadogEmitter(logger, clock, time.Duration(cfg.ReportInterval), cfg.DataDogAPIKey, cfg.DataDogAppKey, cfg.MetricPrefix)
+		return metrics.NewMultiEmitter(loggregatorEmitter, datadogEmitter), nil
+	default:
+		return loggregatorEmitter, nil
+	}
+}
+
+// encryptionFlagsFromConfig adapts the active_key_label/encryption_keys
+// fields on LocketConfig to encryption.EncryptionFlags, so encryption key
+// material is configured the same way as every other Locket setting (the
+// JSON config file) rather than through separate command-line flags.
+func encryptionFlagsFromConfig(cfg config.LocketConfig) *encryption.EncryptionFlags {
+	return &encryption.EncryptionFlags{
+		ActiveKeyLabel: cfg.ActiveKeyLabel,
+		EncryptionKeys: cfg.EncryptionKeys,
+	}
+}
+
+func initializeLockDB(
+	logger lager.Logger,
+	cfg config.LocketConfig,
+	clock clock.Clock,
+	emitter metrics.Emitter,
+	keyManager encryption.KeyManager,
+	cryptor encryption.Cryptor,
+) (db.LockDB, ifrit.Runner, error) {
+	if cfg.DatabaseDriver == "etcd" {
+		tlsConfig, err := cfhttp.NewTLSConfig(cfg.ETCDOptions.CertFile, cfg.ETCDOptions.KeyFile, cfg.ETCDOptions.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(cfg.ETCDOptions.ClientSessionCacheSize)
+
+		etcdDB, err := db.NewETCDDB(logger, cfg.ETCDOptions.ClusterUrls, tlsConfig, clock)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		notifier := metrics.NewETCDDBMetricsNotifier(logger, clock, emitter, time.Duration(cfg.ReportInterval), cfg.ETCDOptions.ClusterUrls)
+		return etcdDB, notifier, nil
+	}
+
+	connectionString := appendExtraConnectionStringParam(logger, cfg)
+
+	sqlConn, err := sql.Open(cfg.DatabaseDriver, connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlConn.SetMaxIdleConns(cfg.MaxOpenDatabaseConnections)
+	sqlConn.SetMaxOpenConns(cfg.MaxOpenDatabaseConnections)
+
+	err = sqlConn.Ping()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dbMonitor := helpers.NewQueryMonitor()
+	monitoredDB := helpers.NewMonitoredDB(sqlConn, dbMonitor)
+
+	sqlDB := db.NewSQLDB(
+		monitoredDB,
+		cfg.DatabaseDriver,
+		guidprovider.DefaultGuidProvider,
+		keyManager,
+		cryptor,
+	)
+
+	notifier := metrics.NewDBMetricsNotifier(logger, clock, emitter, time.Duration(cfg.ReportInterval), sqlDB, dbMonitor)
+	return sqlDB, notifier, nil
+}
+
+func initializeTokenValidator(logger lager.Logger, cfg config.LocketConfig, clock clock.Clock) (handlers.TokenValidator, error) {
+	if cfg.AuthDisabled {
+		return handlers.NewNoopTokenValidator(), nil
+	}
+
+	client, err := uaa_client.NewClient(logger, &cfg.UAAConfig, clock)
+	if err != nil {
+		return nil, err
+	}
+
+	return handlers.NewUAATokenValidator(logger, client), nil
+}
+
+func appendExtraConnectionStringParam(logger lager.Logger, cfg config.LocketConfig) string {
+	driverName := cfg.DatabaseDriver
+	sqlCACertFile := cfg.SQLCACertFile
+	databaseConnectionString := cfg.DatabaseConnectionString
+
 	switch driverName {
 	case "mysql":
-		cfg, err := mysql.ParseDSN(databaseConnectionString)
+		dsnConfig, err := mysql.ParseDSN(databaseConnectionString)
 		if err != nil {
 			logger.Fatal("invalid-db-connection-string", err, lager.Data{"connection-string": databaseConnectionString})
 		}
@@ -195,33 +325,83 @@ func appendExtraConnectionStringParam(logger lager.Logger, driverName, databaseC
 			}
 
 			tlsConfig := &tls.Config{
-				InsecureSkipVerify: false,
+				InsecureSkipVerify: cfg.SQLTLSSkipVerify,
 				RootCAs:            caCertPool,
 			}
 
-			mysql.RegisterTLSConfig("bbs-tls", tlsConfig)
-			cfg.TLSConfig = "bbs-tls"
+			// Suffix the registered name with a hash of the cert bundle so
+			// that rotating SQLCACertFile across restarts never collides
+			// with a stale registration left behind by go-sql-driver/mysql,
+			// which keeps registered TLS configs in a package-level map for
+			// the lifetime of the process.
+			tlsConfigName := fmt.Sprintf("bbs-tls-%x", sha256.Sum256(certBytes))[:20]
+			mysql.RegisterTLSConfig(tlsConfigName, tlsConfig)
+			dsnConfig.TLSConfig = tlsConfigName
 		}
-		cfg.Timeout = 10 * time.Minute
-		cfg.ReadTimeout = 10 * time.Minute
-		cfg.WriteTimeout = 10 * time.Minute
-		databaseConnectionString = cfg.FormatDSN()
+		dsnConfig.Timeout = 10 * time.Minute
+		dsnConfig.ReadTimeout = 10 * time.Minute
+		dsnConfig.WriteTimeout = 10 * time.Minute
+		databaseConnectionString = dsnConfig.FormatDSN()
 	case "postgres":
 		var err error
 		databaseConnectionString, err = pq.ParseURL(databaseConnectionString)
 		if err != nil {
 			logger.Fatal("invalid-db-connection-string", err, lager.Data{"connection-string": databaseConnectionString})
 		}
-		if sqlCACertFile == "" {
-			databaseConnectionString = databaseConnectionString + " sslmode=disable"
-		} else {
-			databaseConnectionString = fmt.Sprintf("%s sslmode=verify-ca sslrootcert=%s", databaseConnectionString, sqlCACertFile)
+
+		sqlTLSMode := cfg.SQLTLSMode
+		if sqlTLSMode == "" {
+			if sqlCACertFile == "" {
+				sqlTLSMode = "disable"
+			} else {
+				sqlTLSMode = "verify-ca"
+			}
 		}
+
+		databaseConnectionString = fmt.Sprintf("%s sslmode=%s", databaseConnectionString, sqlTLSMode)
+		if sqlCACertFile != "" {
+			databaseConnectionString = fmt.Sprintf("%s sslrootcert=%s", databaseConnectionString, sqlCACertFile)
+		}
+		if cfg.SQLClientCertFile != "" {
+			databaseConnectionString = fmt.Sprintf("%s sslcert=%s", databaseConnectionString, cfg.SQLClientCertFile)
+		}
+		if cfg.SQLClientKeyFile != "" {
+			databaseConnectionString = fmt.Sprintf("%s sslkey=%s", databaseConnectionString, cfg.SQLClientKeyFile)
+		}
+
+		// Catches a malformed DSN (e.g. a stray trailing space from the
+		// concatenation above) here instead of failing opaquely later at
+		// sqlConn.Ping. pq.ParseURL can't be reused for this: it only
+		// understands the postgres:// URL form, and by this point
+		// databaseConnectionString has already been converted out of it
+		// into keyword=value form.
+		if err := validateKeywordValueDSN(databaseConnectionString); err != nil {
+			logger.Fatal("invalid-resolved-db-connection-string", err)
+		}
+		logger.Debug("resolved-db-connection-string", lager.Data{"connection-string": redactPassword(databaseConnectionString)})
 	}
 
 	return databaseConnectionString
 }
 
+var keywordValueDSNRegexp = regexp.MustCompile(`^(?:[a-zA-Z_]+=(?:'[^']*'|\S+))(?:\s+[a-zA-Z_]+=(?:'[^']*'|\S+))*$`)
+
+// validateKeywordValueDSN does a lightweight syntactic check of a
+// keyword=value Postgres DSN, the form libpq itself accepts, catching
+// things like a stray trailing space left over from string concatenation.
+func validateKeywordValueDSN(dsn string) error {
+	if !keywordValueDSNRegexp.MatchString(dsn) {
+		return fmt.Errorf("malformed keyword/value DSN")
+	}
+	return nil
+}
+
+var passwordParamRegexp = regexp.MustCompile(`(?i)password='[^']*'|password=\S+`)
+
+func redactPassword(connectionString string) string {
+	return passwordParamRegexp.ReplaceAllString(connectionString, "password=REDACTED")
+}
+
 func initializeRegistrationRunner(
 	logger lager.Logger,
 	consulClient consuladapter.Client,
@@ -237,3 +417,43 @@ func initializeRegistrationRunner(
 	}
 	return locket.NewRegistrationRunner(logger, registration, consulClient, locket.RetryInterval, clock)
 }
+
+func runReEncrypt() {
+	cfg, err := config.NewLocketConfig(*configFilePath)
+	if err != nil {
+		panic("invalid-config-file: " + err.Error())
+	}
+
+	logger, _ := lagerflags.NewFromConfig("locket-re-encrypt", cfg.LagerConfig)
+
+	keyManager, err := encryptionFlagsFromConfig(cfg).Parse()
+	if err != nil {
+		logger.Fatal("invalid-encryption-keys", err)
+	}
+	cryptor := encryption.NewCryptor(keyManager, rand.Reader)
+
+	connectionString := appendExtraConnectionStringParam(logger, cfg)
+
+	sqlConn, err := sql.Open(cfg.DatabaseDriver, connectionString)
+	if err != nil {
+		logger.Fatal("failed-to-open-sql", err)
+	}
+	defer sqlConn.Close()
+
+	err = sqlConn.Ping()
+	if err != nil {
+		logger.Fatal("sql-failed-to-connect", err)
+	}
+
+	dbMonitor := helpers.NewQueryMonitor()
+	monitoredDB := helpers.NewMonitoredDB(sqlConn, dbMonitor)
+
+	sqlDB := db.NewSQLDB(monitoredDB, cfg.DatabaseDriver, guidprovider.DefaultGuidProvider, keyManager, cryptor)
+
+	err = sqlDB.PerformEncryption(logger)
+	if err != nil {
+		logger.Fatal("failed-to-re-encrypt", err)
+	}
+
+	logger.Info("re-encrypt-complete")
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/models"
+)
+
+// ErrLockCollision is returned by Lock when the resource is already held by
+// a different owner. Callers must not treat this as a successful acquisition
+// (and must not feed the disputed resource on to TTL tracking).
+var ErrLockCollision = errors.New("lock-collision")
+
+//go:generate counterfeiter . LockDB
+
+type LockDB interface {
+	Lock(logger lager.Logger, resource *models.Resource) (*models.Resource, error)
+	Release(logger lager.Logger, resource *models.Resource) error
+	Fetch(logger lager.Logger, key string) (*models.Resource, error)
+	FetchAll(logger lager.Logger, resourceType string) ([]*models.Resource, error)
+	Count(logger lager.Logger, resourceType string) (int, error)
+	CreateLockTable(logger lager.Logger) error
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+
+	"code.cloudfoundry.org/locket/models"
+	"go.etcd.io/etcd/clientv3"
+)
+
+var errKeyNotFound = errors.New("key-not-found")
+
+type etcdValue struct {
+	Owner    string `json:"owner"`
+	Value    string `json:"value"`
+	TypeCode int32  `json:"type_code"`
+	LeaseID  int64  `json:"lease_id"`
+}
+
+func marshalResource(resource *models.Resource, leaseID clientv3.LeaseID) (string, error) {
+	bytes, err := json.Marshal(etcdValue{
+		Owner:    resource.Owner,
+		Value:    resource.Value,
+		TypeCode: resource.TypeCode,
+		LeaseID:  int64(leaseID),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func unmarshalResource(data []byte) (*models.Resource, error) {
+	var v etcdValue
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return &models.Resource{
+		Owner:    v.Owner,
+		Value:    v.Value,
+		TypeCode: v.TypeCode,
+	}, nil
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/models"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// errLockContended is returned by Lock when the compare-and-swap against the
+// key's lease keeps losing to a concurrent writer across every retry.
+var errLockContended = errors.New("lock-contended")
+
+const lockAcquireRetries = 3
+
+// etcdLease tracks the lease this process is holding for a given lock key,
+// so a heartbeat Lock call from the same owner renews the existing lease
+// instead of granting a new one and abandoning the old one's KeepAlive.
+type etcdLease struct {
+	owner   string
+	leaseID clientv3.LeaseID
+	cancel  context.CancelFunc
+}
+
+// ETCDDB implements LockDB against an etcd v3 cluster. Each lock is backed by
+// its own lease, whose ID is also written into the stored value. Release
+// revokes the lease recorded in this process's in-memory leases map; the
+// stored lease ID is not read back, so a lease granted by another process is
+// not revoked (the key is still deleted). Expiration is handled entirely by
+// etcd, so no Burglar/LockPick sweep is needed.
+type ETCDDB struct {
+	client *clientv3.Client
+	clock  clock.Clock
+
+	mutex  sync.Mutex
+	leases map[string]*etcdLease
+}
+
+func NewETCDDB(logger lager.Logger, clusterUrls []string, tlsConfig *tls.Config, clock clock.Clock) (*ETCDDB, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: clusterUrls,
+		TLS:       tlsConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ETCDDB{
+		client: client,
+		clock:  clock,
+		leases: map[string]*etcdLease{},
+	}, nil
+}
+
+func (e *ETCDDB) CreateLockTable(logger lager.Logger) error {
+	return nil
+}
+
+// Lock grants or renews the lease backing resource.Key and writes resource
+// under it, using a compare-and-swap against the key's mod revision so a
+// concurrent Lock from a different owner can't race this one into
+// overwriting it: exactly one of the two Txns commits, and the loser retries
+// against whatever the winner just wrote.
+func (e *ETCDDB) Lock(logger lager.Logger, resource *models.Resource) (*models.Resource, error) {
+	logger = logger.Session("etcd-lock", lager.Data{"key": resource.Key})
+	ctx := context.Background()
+
+	for attempt := 0; attempt < lockAcquireRetries; attempt++ {
+		resp, err := e.client.Get(ctx, resource.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		var existing *models.Resource
+		var modRevision int64
+		if len(resp.Kvs) > 0 {
+			existing, err = unmarshalResource(resp.Kvs[0].Value)
+			if err != nil {
+				return nil, err
+			}
+			existing.Key = resource.Key
+			modRevision = resp.Kvs[0].ModRevision
+		}
+
+		if existing != nil && existing.Owner != "" && existing.Owner != resource.Owner {
+			return nil, ErrLockCollision
+		}
+
+		leaseID, err := e.acquireLease(ctx, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := marshalResource(resource, leaseID)
+		if err != nil {
+			return nil, err
+		}
+
+		var cmp clientv3.Cmp
+		if existing == nil {
+			cmp = clientv3.Compare(clientv3.CreateRevision(resource.Key), "=", 0)
+		} else {
+			cmp = clientv3.Compare(clientv3.ModRevision(resource.Key), "=", modRevision)
+		}
+
+		txnResp, err := e.client.Txn(ctx).
+			If(cmp).
+			Then(clientv3.OpPut(resource.Key, value, clientv3.WithLease(leaseID))).
+			Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		if txnResp.Succeeded {
+			return resource, nil
+		}
+
+		logger.Debug("lock-contended-retrying", lager.Data{"attempt": attempt})
+	}
+
+	return nil, errLockContended
+}
+
+// acquireLease returns the lease this process already holds for resource.Key
+// when it was granted to the same owner, or grants a fresh one otherwise. A
+// lease's KeepAlive runs for as long as the lease is reused, so a caller that
+// repeatedly calls Lock to heartbeat a held resource never grants more than
+// one lease or spawns more than one KeepAlive goroutine for it.
+func (e *ETCDDB) acquireLease(ctx context.Context, resource *models.Resource) (clientv3.LeaseID, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if lease, ok := e.leases[resource.Key]; ok && lease.owner == resource.Owner {
+		return lease.leaseID, nil
+	}
+
+	leaseCtx, cancel := context.WithCancel(context.Background())
+	lease, err := e.client.Grant(leaseCtx, int64(ttlFor(resource).Seconds()))
+	if err != nil {
+		cancel()
+		return 0, err
+	}
+
+	keepAliveCh, err := e.client.KeepAlive(leaseCtx, lease.ID)
+	if err != nil {
+		cancel()
+		return 0, err
+	}
+	go drainKeepAlive(keepAliveCh)
+
+	if old, ok := e.leases[resource.Key]; ok {
+		old.cancel()
+		go e.client.Revoke(context.Background(), old.leaseID)
+	}
+
+	e.leases[resource.Key] = &etcdLease{owner: resource.Owner, leaseID: lease.ID, cancel: cancel}
+	return lease.ID, nil
+}
+
+func (e *ETCDDB) Release(logger lager.Logger, resource *models.Resource) error {
+	logger = logger.Session("etcd-release", lager.Data{"key": resource.Key})
+
+	e.mutex.Lock()
+	lease, ok := e.leases[resource.Key]
+	if ok {
+		delete(e.leases, resource.Key)
+	}
+	e.mutex.Unlock()
+
+	if ok {
+		lease.cancel()
+		if _, err := e.client.Revoke(context.Background(), lease.leaseID); err != nil {
+			logger.Error("failed-to-revoke-lease", err)
+		}
+	}
+
+	_, err := e.client.Delete(context.Background(), resource.Key)
+	return err
+}
+
+func (e *ETCDDB) Fetch(logger lager.Logger, key string) (*models.Resource, error) {
+	resp, err := e.client.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, errKeyNotFound
+	}
+
+	resource, err := unmarshalResource(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	resource.Key = string(resp.Kvs[0].Key)
+
+	return resource, nil
+}
+
+func (e *ETCDDB) FetchAll(logger lager.Logger, resourceType string) ([]*models.Resource, error) {
+	resp, err := e.client.Get(context.Background(), "", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	typeCode := models.ResourceTypeCode(resourceType)
+	resources := make([]*models.Resource, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		resource, err := unmarshalResource(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		if resource.TypeCode != typeCode {
+			continue
+		}
+		resource.Key = string(kv.Key)
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
+
+// Count has no etcd-side way to filter WithCountOnly by value, so it fetches
+// every key under the prefix and counts the ones matching resourceType
+// in-process.
+func (e *ETCDDB) Count(logger lager.Logger, resourceType string) (int, error) {
+	resources, err := e.FetchAll(logger, resourceType)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(resources), nil
+}
+
+func ttlFor(resource *models.Resource) time.Duration {
+	if resource.TtlInSeconds > 0 {
+		return time.Duration(resource.TtlInSeconds) * time.Second
+	}
+
+	return locketDefaultTTL
+}
+
+const locketDefaultTTL = 15 * time.Second
+
+func drainKeepAlive(ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	for range ch {
+	}
+}
@@ -0,0 +1,419 @@
+package db
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/db/sqldb/helpers"
+	"code.cloudfoundry.org/bbs/encryption"
+	"code.cloudfoundry.org/bbs/guidprovider"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/models"
+)
+
+// ErrOwnerMACMismatch is returned by Fetch/FetchAll when a row's owner_mac
+// doesn't match the recomputed HMAC of its owner under the key named by its
+// key_label, meaning
+// the owner column was tampered with outside of Lock/PerformEncryption.
+var ErrOwnerMACMismatch = errors.New("owner-mac-mismatch")
+
+type SQLDB struct {
+	db           helpers.QueryableDB
+	driver       string
+	guidProvider guidprovider.GUIDProvider
+	keyManager   encryption.KeyManager
+	cryptor      encryption.Cryptor
+}
+
+func NewSQLDB(
+	db helpers.QueryableDB,
+	driver string,
+	guidProvider guidprovider.GUIDProvider,
+	keyManager encryption.KeyManager,
+	cryptor encryption.Cryptor,
+) *SQLDB {
+	return &SQLDB{
+		db:           db,
+		driver:       driver,
+		guidProvider: guidProvider,
+		keyManager:   keyManager,
+		cryptor:      cryptor,
+	}
+}
+
+func (s *SQLDB) CreateLockTable(logger lager.Logger) error {
+	var createTableString string
+	switch s.driver {
+	case "mysql":
+		createTableString = `CREATE TABLE IF NOT EXISTS locks(
+			path VARCHAR(255) PRIMARY KEY,
+			owner VARCHAR(255),
+			owner_mac VARCHAR(255),
+			value MEDIUMTEXT,
+			type_code INT,
+			modified_index BIGINT DEFAULT 0,
+			modified_id VARCHAR(36) DEFAULT '',
+			key_label VARCHAR(255) DEFAULT '',
+			format SMALLINT DEFAULT 0
+		)`
+	default:
+		createTableString = `CREATE TABLE IF NOT EXISTS locks(
+			path VARCHAR(255) PRIMARY KEY,
+			owner VARCHAR(255),
+			owner_mac VARCHAR(255),
+			value TEXT,
+			type_code INT,
+			modified_index BIGINT DEFAULT 0,
+			modified_id VARCHAR(36) DEFAULT '',
+			key_label VARCHAR(255) DEFAULT '',
+			format SMALLINT DEFAULT 0
+		)`
+	}
+
+	_, err := s.db.Exec(createTableString)
+	if err != nil {
+		return err
+	}
+
+	return s.addEncryptionColumns(logger)
+}
+
+// addEncryptionColumns brings a locks table created before encryption was
+// introduced up to the current schema. The ALTER TABLE is best-effort: on a
+// fresh table the columns already exist from createTableString, and each
+// driver's "column already exists" error is swallowed so upgrades and
+// mid-rotation restarts are idempotent.
+func (s *SQLDB) addEncryptionColumns(logger lager.Logger) error {
+	logger = logger.Session("add-encryption-columns")
+
+	columns := []string{
+		`ALTER TABLE locks ADD COLUMN owner_mac VARCHAR(255)`,
+		`ALTER TABLE locks ADD COLUMN key_label VARCHAR(255) DEFAULT ''`,
+		`ALTER TABLE locks ADD COLUMN format SMALLINT DEFAULT 0`,
+	}
+
+	for _, alter := range columns {
+		_, err := s.db.Exec(alter)
+		if err != nil && !isDuplicateColumnError(s.driver, err) {
+			logger.Error("failed-to-add-column", err, lager.Data{"statement": alter})
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebind rewrites a query's MySQL-style "?" placeholders into "$1", "$2", ...
+// for drivers that need it, so the same query text works against every
+// CreateLockTable-supported driver instead of only the one the literal "?"
+// syntax happens to match.
+func (s *SQLDB) rebind(query string) string {
+	if s.driver == "mysql" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func isDuplicateColumnError(driver string, err error) bool {
+	switch driver {
+	case "mysql":
+		return strings.Contains(err.Error(), "1060") || strings.Contains(err.Error(), "Duplicate column")
+	default:
+		return strings.Contains(err.Error(), "already exists")
+	}
+}
+
+// formatEncrypted is written to the format column of every row whose value
+// column holds ciphertext produced by the active Cryptor rather than
+// plaintext. Reads and PerformEncryption do not consult the format column:
+// they tell plaintext rows (empty key_label) and rows awaiting rotation
+// (key_label other than the active key's) apart by key_label.
+const formatEncrypted = 1
+
+func (s *SQLDB) encryptValue(value string) (string, string, error) {
+	cipherText, label, err := s.cryptor.Encrypt([]byte(value))
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(cipherText), label, nil
+}
+
+func (s *SQLDB) decryptValue(value, label string) (string, error) {
+	if label == "" {
+		return value, nil
+	}
+
+	cipherText, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	plainText, err := s.cryptor.Decrypt(cipherText, label)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainText), nil
+}
+
+// ownerMAC authenticates resource.Owner with the real secret material behind
+// keyLabel's key (not the label itself, which sits in the clear in the same
+// row) so a row whose owner was tampered with outside of Lock/PerformEncryption
+// can be detected on read.
+func (s *SQLDB) ownerMAC(owner, keyLabel string) (string, error) {
+	if keyLabel == "" {
+		return "", nil
+	}
+
+	key, err := s.keyManager.DecryptionKey(keyLabel)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(encryption.HMAC(key.MACKey(), []byte(owner))), nil
+}
+
+// verifyOwnerMAC recomputes the expected owner_mac for owner/keyLabel and
+// compares it against the stored gotMAC in constant time.
+func (s *SQLDB) verifyOwnerMAC(owner, keyLabel, gotMAC string) error {
+	if keyLabel == "" {
+		return nil
+	}
+
+	expectedMAC, err := s.ownerMAC(owner, keyLabel)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal([]byte(expectedMAC), []byte(gotMAC)) {
+		return ErrOwnerMACMismatch
+	}
+
+	return nil
+}
+
+// Lock runs the read-then-write that decides whether resource's owner wins
+// the lock inside a single transaction, row-locking the existing record (if
+// any) with SELECT ... FOR UPDATE first. That closes the race where two
+// concurrent callers with different owners both see no conflicting owner and
+// both unconditionally write: the second caller now blocks on the row lock
+// until the first transaction commits, then re-evaluates against what the
+// first caller actually wrote.
+func (s *SQLDB) Lock(logger lager.Logger, resource *models.Resource) (*models.Resource, error) {
+	logger = logger.Session("sql-lock", lager.Data{"key": resource.Key})
+
+	var result *models.Resource
+
+	err := s.db.Transact(logger, func(logger lager.Logger, tx helpers.Tx) error {
+		var existingOwner string
+		row := tx.QueryRow(s.rebind(`SELECT owner FROM locks WHERE path = ? FOR UPDATE`), resource.Key)
+		err := row.Scan(&existingOwner)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if existingOwner != "" && existingOwner != resource.Owner {
+			return ErrLockCollision
+		}
+
+		encryptedValue, keyLabel, err := s.encryptValue(resource.Value)
+		if err != nil {
+			return err
+		}
+		ownerMAC, err := s.ownerMAC(resource.Owner, keyLabel)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(s.rebind(s.upsertLockQuery()),
+			resource.Key, resource.Owner, ownerMAC, encryptedValue, resource.TypeCode, keyLabel, formatEncrypted,
+			resource.Owner, ownerMAC, encryptedValue, keyLabel, formatEncrypted,
+		)
+		if err != nil {
+			return err
+		}
+
+		result = resource
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// upsertLockQuery returns the dialect-specific atomic insert-or-update for
+// the locks table. MySQL and Postgres spell "upsert" differently, the same
+// split CreateLockTable already makes for the table's column types.
+func (s *SQLDB) upsertLockQuery() string {
+	if s.driver == "mysql" {
+		return `
+			INSERT INTO locks (path, owner, owner_mac, value, type_code, modified_index, key_label, format)
+			VALUES (?, ?, ?, ?, ?, 1, ?, ?)
+			ON DUPLICATE KEY UPDATE owner = ?, owner_mac = ?, value = ?, key_label = ?, format = ?, modified_index = modified_index + 1
+		`
+	}
+
+	return `
+		INSERT INTO locks (path, owner, owner_mac, value, type_code, modified_index, key_label, format)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET owner = ?, owner_mac = ?, value = ?, key_label = ?, format = ?, modified_index = locks.modified_index + 1
+	`
+}
+
+func (s *SQLDB) Release(logger lager.Logger, resource *models.Resource) error {
+	logger = logger.Session("sql-release", lager.Data{"key": resource.Key})
+
+	_, err := s.db.Exec(s.rebind(`DELETE FROM locks WHERE path = ? AND owner = ?`), resource.Key, resource.Owner)
+	return err
+}
+
+func (s *SQLDB) Fetch(logger lager.Logger, key string) (*models.Resource, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT path, owner, value, type_code, modified_index, key_label, owner_mac FROM locks WHERE path = ?`), key)
+
+	var keyLabel string
+	var ownerMAC sql.NullString
+	resource := &models.Resource{}
+	err := row.Scan(&resource.Key, &resource.Owner, &resource.Value, &resource.TypeCode, &resource.ModifiedIndex, &keyLabel, &ownerMAC)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyOwnerMAC(resource.Owner, keyLabel, ownerMAC.String); err != nil {
+		return nil, err
+	}
+
+	resource.Value, err = s.decryptValue(resource.Value, keyLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+func (s *SQLDB) FetchAll(logger lager.Logger, resourceType string) ([]*models.Resource, error) {
+	rows, err := s.db.Query(
+		s.rebind(`SELECT path, owner, value, type_code, modified_index, key_label, owner_mac FROM locks WHERE type_code = ?`),
+		models.ResourceTypeCode(resourceType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []*models.Resource{}
+	for rows.Next() {
+		var keyLabel string
+		var ownerMAC sql.NullString
+		resource := &models.Resource{}
+		err := rows.Scan(&resource.Key, &resource.Owner, &resource.Value, &resource.TypeCode, &resource.ModifiedIndex, &keyLabel, &ownerMAC)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.verifyOwnerMAC(resource.Owner, keyLabel, ownerMAC.String); err != nil {
+			return nil, err
+		}
+
+		resource.Value, err = s.decryptValue(resource.Value, keyLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, resource)
+	}
+
+	return resources, rows.Err()
+}
+
+func (s *SQLDB) Count(logger lager.Logger, resourceType string) (int, error) {
+	row := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM locks WHERE type_code = ?`), models.ResourceTypeCode(resourceType))
+
+	var count int
+	err := row.Scan(&count)
+	return count, err
+}
+
+// PerformEncryption streams every row in the locks table, decrypting with
+// whichever key is registered under its key_label and rewriting it encrypted
+// with the currently active key. It is safe to run against a table that is
+// mid-rotation: rows already under the active label are left untouched.
+func (s *SQLDB) PerformEncryption(logger lager.Logger) error {
+	logger = logger.Session("perform-encryption")
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	activeKey, err := s.keyManager.EncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.Query(`SELECT path, owner, value, key_label FROM locks`)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		path, owner, value, keyLabel string
+	}
+
+	toReEncrypt := []row{}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.path, &r.owner, &r.value, &r.keyLabel); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if r.keyLabel != activeKey.Label() {
+			toReEncrypt = append(toReEncrypt, r)
+		}
+	}
+	rows.Close()
+
+	for _, r := range toReEncrypt {
+		plainText, err := s.decryptValue(r.value, r.keyLabel)
+		if err != nil {
+			logger.Error("failed-to-decrypt-row", err, lager.Data{"path": r.path})
+			return err
+		}
+
+		encryptedValue, keyLabel, err := s.encryptValue(plainText)
+		if err != nil {
+			return err
+		}
+		ownerMAC, err := s.ownerMAC(r.owner, keyLabel)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.db.Exec(s.rebind(`
+			UPDATE locks SET value = ?, key_label = ?, owner_mac = ?, format = ? WHERE path = ?
+		`), encryptedValue, keyLabel, ownerMAC, formatEncrypted, r.path)
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("re-encrypted-row", lager.Data{"path": r.path})
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package expiration
+
+import (
+	"encoding/base64"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs/encryption"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/db"
+	"code.cloudfoundry.org/locket/metrics"
+)
+
+type Burglar struct {
+	logger        lager.Logger
+	db            db.LockDB
+	lockPick      LockPick
+	clock         clock.Clock
+	retryInterval time.Duration
+	metronClient  metrics.Emitter
+	cryptor       encryption.Cryptor
+}
+
+func NewBurglar(
+	logger lager.Logger,
+	db db.LockDB,
+	lockPick LockPick,
+	clock clock.Clock,
+	retryInterval time.Duration,
+	metronClient metrics.Emitter,
+	cryptor encryption.Cryptor,
+) *Burglar {
+	return &Burglar{
+		logger:        logger,
+		db:            db,
+		lockPick:      lockPick,
+		clock:         clock,
+		retryInterval: retryInterval,
+		metronClient:  metronClient,
+		cryptor:       cryptor,
+	}
+}
+
+func (b *Burglar) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := b.logger.Session("burglar")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	timer := b.clock.NewTimer(b.retryInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-timer.C():
+			b.reapExpiredLocks(logger)
+			timer.Reset(b.retryInterval)
+		}
+	}
+}
+
+// reapExpiredLocks releases every lock whose TTL has lapsed since the last
+// sweep and emits an audit event for each one. A release failure is logged
+// and skipped rather than aborting the sweep, so one bad row never blocks
+// every other expired lock from being reaped on schedule.
+func (b *Burglar) reapExpiredLocks(logger lager.Logger) {
+	for _, resource := range b.lockPick.ScanForExpiredLocks(logger) {
+		err := b.db.Release(logger, resource)
+		if err != nil {
+			logger.Error("failed-to-release-expired-lock", err, lager.Data{"key": resource.Key})
+			continue
+		}
+
+		b.emitExpiredLockAudit(logger, resource.Key, resource.Owner)
+	}
+}
+
+// emitExpiredLockAudit seals the expired lock's key and owner with the active
+// encryption key before logging, so audit trails never carry plaintext lock
+// ownership data at rest or in flight to the log sink.
+func (b *Burglar) emitExpiredLockAudit(logger lager.Logger, key, owner string) {
+	cipherText, label, err := b.cryptor.Encrypt([]byte(key + ":" + owner))
+	if err != nil {
+		logger.Error("failed-to-encrypt-audit-event", err)
+		return
+	}
+
+	logger.Info("lock-expired", lager.Data{
+		"key_label": label,
+		"audit":     base64.StdEncoding.EncodeToString(cipherText),
+	})
+}
@@ -0,0 +1,110 @@
+package expiration
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/bbs/encryption"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/locket/models"
+)
+
+// fakeLockPick hands reapExpiredLocks a fixed, one-shot batch of expired
+// resources so the test can drive Burglar without a real TTL sweep.
+type fakeLockPick struct {
+	expired []*models.Resource
+}
+
+func (f *fakeLockPick) RegisterTTL(logger lager.Logger, resource *models.Resource) {}
+
+func (f *fakeLockPick) ScanForExpiredLocks(logger lager.Logger) []*models.Resource {
+	batch := f.expired
+	f.expired = nil
+	return batch
+}
+
+// fakeLockDB records every key Release was called with, failing it when the
+// key is listed in failKeys.
+type fakeLockDB struct {
+	released []string
+	failKeys map[string]bool
+}
+
+func (f *fakeLockDB) Lock(logger lager.Logger, resource *models.Resource) (*models.Resource, error) {
+	return resource, nil
+}
+
+func (f *fakeLockDB) Release(logger lager.Logger, resource *models.Resource) error {
+	if f.failKeys[resource.Key] {
+		return errors.New("release-failed")
+	}
+	f.released = append(f.released, resource.Key)
+	return nil
+}
+
+func (f *fakeLockDB) Fetch(logger lager.Logger, key string) (*models.Resource, error) {
+	return nil, errors.New("not-implemented")
+}
+
+func (f *fakeLockDB) FetchAll(logger lager.Logger, resourceType string) ([]*models.Resource, error) {
+	return nil, nil
+}
+
+func (f *fakeLockDB) Count(logger lager.Logger, resourceType string) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeLockDB) CreateLockTable(logger lager.Logger) error {
+	return nil
+}
+
+func testCryptor(t *testing.T) encryption.Cryptor {
+	t.Helper()
+
+	flags := &encryption.EncryptionFlags{
+		ActiveKeyLabel: "label1",
+		EncryptionKeys: map[string]string{"label1": "passphrase-1"},
+	}
+	km, err := flags.Parse()
+	if err != nil {
+		t.Fatalf("parse encryption flags: %s", err)
+	}
+
+	return encryption.NewCryptor(km, rand.Reader)
+}
+
+func TestReapExpiredLocksReleasesEveryExpiredResource(t *testing.T) {
+	logger := lagertest.NewTestLogger("burglar")
+	lockPick := &fakeLockPick{expired: []*models.Resource{
+		{Key: "key-1", Owner: "owner-1"},
+		{Key: "key-2", Owner: "owner-2"},
+	}}
+	lockDB := &fakeLockDB{failKeys: map[string]bool{}}
+	burglar := NewBurglar(logger, lockDB, lockPick, fakeclock.NewFakeClock(time.Now()), time.Second, nil, testCryptor(t))
+
+	burglar.reapExpiredLocks(logger)
+
+	if len(lockDB.released) != 2 || lockDB.released[0] != "key-1" || lockDB.released[1] != "key-2" {
+		t.Fatalf("expected both expired locks to be released, got %v", lockDB.released)
+	}
+}
+
+func TestReapExpiredLocksSkipsPastAReleaseFailure(t *testing.T) {
+	logger := lagertest.NewTestLogger("burglar")
+	lockPick := &fakeLockPick{expired: []*models.Resource{
+		{Key: "key-1", Owner: "owner-1"},
+		{Key: "key-2", Owner: "owner-2"},
+	}}
+	lockDB := &fakeLockDB{failKeys: map[string]bool{"key-1": true}}
+	burglar := NewBurglar(logger, lockDB, lockPick, fakeclock.NewFakeClock(time.Now()), time.Second, nil, testCryptor(t))
+
+	burglar.reapExpiredLocks(logger)
+
+	if len(lockDB.released) != 1 || lockDB.released[0] != "key-2" {
+		t.Fatalf("expected the failure on key-1 to be skipped and key-2 still released, got %v", lockDB.released)
+	}
+}
@@ -0,0 +1,82 @@
+package expiration
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/db"
+	"code.cloudfoundry.org/locket/metrics"
+	"code.cloudfoundry.org/locket/models"
+)
+
+//go:generate counterfeiter . LockPick
+
+type LockPick interface {
+	RegisterTTL(logger lager.Logger, resource *models.Resource)
+	ScanForExpiredLocks(logger lager.Logger) []*models.Resource
+}
+
+type registration struct {
+	resource  *models.Resource
+	expiresAt time.Time
+}
+
+type lockPick struct {
+	db           db.LockDB
+	clock        clock.Clock
+	metronClient metrics.Emitter
+
+	mutex         sync.Mutex
+	registrations map[string]registration
+}
+
+func NewLockPick(db db.LockDB, clock clock.Clock, metronClient metrics.Emitter) *lockPick {
+	return &lockPick{
+		db:            db,
+		clock:         clock,
+		metronClient:  metronClient,
+		registrations: map[string]registration{},
+	}
+}
+
+// RegisterTTL records the deadline by which resource's owner must re-Lock to
+// keep holding it, overwriting any deadline from a previous Lock call for the
+// same key. A non-positive TtlInSeconds means the lock never expires on its
+// own, so any existing registration for it is dropped.
+func (l *lockPick) RegisterTTL(logger lager.Logger, resource *models.Resource) {
+	logger = logger.Session("register-ttl", lager.Data{"key": resource.Key})
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if resource.TtlInSeconds <= 0 {
+		delete(l.registrations, resource.Key)
+		return
+	}
+
+	expiresAt := l.clock.Now().Add(time.Duration(resource.TtlInSeconds) * time.Second)
+	l.registrations[resource.Key] = registration{resource: resource, expiresAt: expiresAt}
+
+	logger.Debug("registered", lager.Data{"expires_at": expiresAt})
+}
+
+// ScanForExpiredLocks removes and returns every registration whose deadline
+// has passed, so the Burglar can release each one exactly once.
+func (l *lockPick) ScanForExpiredLocks(logger lager.Logger) []*models.Resource {
+	now := l.clock.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var expired []*models.Resource
+	for key, reg := range l.registrations {
+		if now.After(reg.expiresAt) {
+			expired = append(expired, reg.resource)
+			delete(l.registrations, key)
+		}
+	}
+
+	return expired
+}
@@ -0,0 +1,66 @@
+package expiration
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/locket/models"
+)
+
+func TestRegisterTTLExpiresAfterTTLElapses(t *testing.T) {
+	fc := fakeclock.NewFakeClock(time.Now())
+	logger := lagertest.NewTestLogger("lock-pick")
+	lp := NewLockPick(nil, fc, nil)
+
+	lp.RegisterTTL(logger, &models.Resource{Key: "key-1", Owner: "owner-1", TtlInSeconds: 10})
+
+	if expired := lp.ScanForExpiredLocks(logger); len(expired) != 0 {
+		t.Fatalf("expected no expired locks before the TTL elapses, got %d", len(expired))
+	}
+
+	fc.Increment(11 * time.Second)
+
+	expired := lp.ScanForExpiredLocks(logger)
+	if len(expired) != 1 || expired[0].Key != "key-1" {
+		t.Fatalf("expected key-1 to be reported expired, got %+v", expired)
+	}
+
+	// A resource is reported at most once: the scan removes it.
+	if expired := lp.ScanForExpiredLocks(logger); len(expired) != 0 {
+		t.Fatalf("expected the expired lock to be removed after being scanned, got %d", len(expired))
+	}
+}
+
+func TestRegisterTTLRenewsOnReRegistration(t *testing.T) {
+	fc := fakeclock.NewFakeClock(time.Now())
+	logger := lagertest.NewTestLogger("lock-pick")
+	lp := NewLockPick(nil, fc, nil)
+
+	lp.RegisterTTL(logger, &models.Resource{Key: "key-1", Owner: "owner-1", TtlInSeconds: 10})
+	fc.Increment(5 * time.Second)
+	lp.RegisterTTL(logger, &models.Resource{Key: "key-1", Owner: "owner-1", TtlInSeconds: 10})
+	fc.Increment(6 * time.Second)
+
+	// 11s have elapsed since the first registration but only 6s since the
+	// renewal, so the lock must not be expired yet.
+	if expired := lp.ScanForExpiredLocks(logger); len(expired) != 0 {
+		t.Fatalf("expected the renewed registration's deadline to win, got %d expired", len(expired))
+	}
+}
+
+func TestRegisterTTLNonPositiveClearsRegistration(t *testing.T) {
+	fc := fakeclock.NewFakeClock(time.Now())
+	logger := lagertest.NewTestLogger("lock-pick")
+	lp := NewLockPick(nil, fc, nil)
+
+	lp.RegisterTTL(logger, &models.Resource{Key: "key-1", Owner: "owner-1", TtlInSeconds: 10})
+	lp.RegisterTTL(logger, &models.Resource{Key: "key-1", Owner: "owner-1", TtlInSeconds: 0})
+
+	fc.Increment(time.Hour)
+
+	if expired := lp.ScanForExpiredLocks(logger); len(expired) != 0 {
+		t.Fatalf("expected a zero-TTL registration to never expire, got %d", len(expired))
+	}
+}
@@ -0,0 +1,43 @@
+package expiration
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/models"
+)
+
+// NewNoopLockPick returns a LockPick that does nothing, for backends such as
+// etcd where lease TTLs already handle expiration and a sweep would just
+// race the backend's own cleanup.
+func NewNoopLockPick() LockPick {
+	return &noopLockPick{}
+}
+
+type noopLockPick struct{}
+
+func (n *noopLockPick) RegisterTTL(logger lager.Logger, resource *models.Resource) {}
+
+func (n *noopLockPick) ScanForExpiredLocks(logger lager.Logger) []*models.Resource { return nil }
+
+// NewNoopBurglar returns an ifrit.Runner that starts immediately and exits
+// cleanly on signal, used in place of Burglar when the selected LockDB
+// backend expires locks itself.
+func NewNoopBurglar(logger lager.Logger) *NoopBurglar {
+	return &NoopBurglar{logger: logger}
+}
+
+type NoopBurglar struct {
+	logger lager.Logger
+}
+
+func (b *NoopBurglar) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := b.logger.Session("noop-burglar")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	<-signals
+	return nil
+}
@@ -0,0 +1,63 @@
+package grpcserver
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type grpcServer struct {
+	logger        lager.Logger
+	listenAddress string
+	tlsConfig     *tls.Config
+	handler       models.LocketServer
+	interceptor   grpc.UnaryServerInterceptor
+}
+
+func NewGRPCServer(
+	logger lager.Logger,
+	listenAddress string,
+	tlsConfig *tls.Config,
+	handler models.LocketServer,
+	interceptor grpc.UnaryServerInterceptor,
+) *grpcServer {
+	return &grpcServer{
+		logger:        logger,
+		listenAddress: listenAddress,
+		tlsConfig:     tlsConfig,
+		handler:       handler,
+		interceptor:   interceptor,
+	}
+}
+
+func (s *grpcServer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := s.logger.Session("grpc-server")
+
+	listener, err := net.Listen("tcp", s.listenAddress)
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(s.tlsConfig))}
+	if s.interceptor != nil {
+		opts = append(opts, grpc.UnaryInterceptor(s.interceptor))
+	}
+
+	server := grpc.NewServer(opts...)
+	models.RegisterLocketServer(server, s.handler)
+
+	go server.Serve(listener)
+
+	close(ready)
+	logger.Info("started")
+
+	<-signals
+
+	server.GracefulStop()
+	return nil
+}
@@ -0,0 +1,91 @@
+package locket
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/metrics"
+	"github.com/tedsuo/ifrit"
+)
+
+// haRunner blocks Run from signalling ready until lock is held, then starts
+// inner. If the lock is lost (its process exits on its own, e.g. the Consul
+// session expired out from under it) inner is signalled and torn down so a
+// standby instance never serves traffic without holding the leader lock.
+type haRunner struct {
+	logger       lager.Logger
+	lock         ifrit.Runner
+	inner        ifrit.Runner
+	clock        clock.Clock
+	metronClient metrics.Emitter
+}
+
+// NewHARunner wraps inner (typically the ordered group of the gRPC server,
+// expiration burglar and metrics notifiers) so it only ever runs on the
+// instance currently holding lock.
+func NewHARunner(logger lager.Logger, lock, inner ifrit.Runner, clock clock.Clock, metronClient metrics.Emitter) ifrit.Runner {
+	return &haRunner{
+		logger:       logger,
+		lock:         lock,
+		inner:        inner,
+		clock:        clock,
+		metronClient: metronClient,
+	}
+}
+
+func (r *haRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := r.logger.Session("ha-runner")
+
+	lockProcess := ifrit.Background(r.lock)
+	select {
+	case <-lockProcess.Ready():
+	case err := <-lockProcess.Wait():
+		return err
+	}
+
+	logger.Info("lock-acquired")
+	r.metronClient.SendMetric("LockHeld.locket-leader", 1)
+	acquiredAt := r.clock.Now()
+
+	innerProcess := ifrit.Background(r.inner)
+	select {
+	case <-innerProcess.Ready():
+		close(ready)
+	case err := <-innerProcess.Wait():
+		lockProcess.Signal(os.Interrupt)
+		<-lockProcess.Wait()
+		return err
+	}
+
+	for {
+		select {
+		case s := <-signals:
+			innerProcess.Signal(s)
+			<-innerProcess.Wait()
+			lockProcess.Signal(s)
+			err := <-lockProcess.Wait()
+			r.emitLockHeldDuration(logger, acquiredAt)
+			return err
+
+		case err := <-lockProcess.Wait():
+			logger.Error("lock-lost", err)
+			innerProcess.Signal(os.Interrupt)
+			<-innerProcess.Wait()
+			r.emitLockHeldDuration(logger, acquiredAt)
+			return err
+
+		case err := <-innerProcess.Wait():
+			lockProcess.Signal(os.Interrupt)
+			<-lockProcess.Wait()
+			r.emitLockHeldDuration(logger, acquiredAt)
+			return err
+		}
+	}
+}
+
+func (r *haRunner) emitLockHeldDuration(logger lager.Logger, acquiredAt time.Time) {
+	r.metronClient.SendMetric("LockHeld.locket-leader", 0)
+	r.metronClient.SendMetric("LockHeldDuration.locket-leader", int(r.clock.Since(acquiredAt)/time.Millisecond))
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"google.golang.org/grpc/metadata"
+)
+
+//go:generate counterfeiter . TokenValidator
+
+// TokenValidator checks an incoming gRPC request's bearer token against a
+// UAA-issued JWT's signature, expiry, and scope.
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, requiredScope string) error
+}
+
+type uaaTokenValidator struct {
+	uaaClient UAAClient
+	logger    lager.Logger
+}
+
+// UAAClient is the subset of uaa_client.Client that token validation needs.
+type UAAClient interface {
+	DecodeToken(uaaToken string, desiredPermissions ...string) error
+}
+
+func NewUAATokenValidator(logger lager.Logger, uaaClient UAAClient) TokenValidator {
+	return &uaaTokenValidator{uaaClient: uaaClient, logger: logger}
+}
+
+func (v *uaaTokenValidator) ValidateToken(ctx context.Context, requiredScope string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return errors.New("no-metadata-in-request")
+	}
+
+	authHeaders := md.Get("authorization")
+	if len(authHeaders) == 0 {
+		return errors.New("no-authorization-header")
+	}
+
+	const bearerPrefix = "bearer "
+	authHeader := authHeaders[0]
+	if len(authHeader) <= len(bearerPrefix) || !strings.EqualFold(authHeader[:len(bearerPrefix)], bearerPrefix) {
+		return errors.New("malformed-authorization-header")
+	}
+	token := authHeader[len(bearerPrefix):]
+
+	return v.uaaClient.DecodeToken(token, requiredScope)
+}
+
+type noopTokenValidator struct{}
+
+func NewNoopTokenValidator() TokenValidator {
+	return &noopTokenValidator{}
+}
+
+func (v *noopTokenValidator) ValidateToken(ctx context.Context, requiredScope string) error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/db"
+	"code.cloudfoundry.org/locket/expiration"
+	"code.cloudfoundry.org/locket/metrics"
+	"code.cloudfoundry.org/locket/models"
+	"google.golang.org/grpc"
+)
+
+// LockScope is the UAA scope required to call Lock, Release, and Fetch once
+// UAA authentication is enabled.
+const LockScope = "locket.lock"
+
+type locketHandler struct {
+	logger           lager.Logger
+	db               db.LockDB
+	lockPick         expiration.LockPick
+	requestsNotifier *metrics.RequestMetricsNotifier
+	tokenValidator   TokenValidator
+	exitCh           chan<- struct{}
+}
+
+func NewLocketHandler(
+	logger lager.Logger,
+	db db.LockDB,
+	lockPick expiration.LockPick,
+	requestsNotifier *metrics.RequestMetricsNotifier,
+	tokenValidator TokenValidator,
+	exitCh chan<- struct{},
+) *locketHandler {
+	return &locketHandler{
+		logger:           logger,
+		db:               db,
+		lockPick:         lockPick,
+		requestsNotifier: requestsNotifier,
+		tokenValidator:   tokenValidator,
+		exitCh:           exitCh,
+	}
+}
+
+// UnaryInterceptor enforces LockScope on every RPC before it reaches Lock,
+// Release, or Fetch, so a single noop/UAA TokenValidator swap at
+// construction time is all a deployment needs to opt in or stay mTLS-only.
+func (h *locketHandler) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	err := h.tokenValidator.ValidateToken(ctx, LockScope)
+	if err != nil {
+		h.logger.Error("failed-to-validate-token", err, lager.Data{"method": info.FullMethod})
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (h *locketHandler) Lock(ctx context.Context, req *models.LockRequest) (*models.LockResponse, error) {
+	logger := h.logger.Session("lock", lager.Data{"key": req.Resource.Key})
+	h.requestsNotifier.IncrementRequestCount()
+
+	req.Resource.TtlInSeconds = req.TtlInSeconds
+
+	resource, err := h.db.Lock(logger, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lockPick.RegisterTTL(logger, resource)
+
+	return &models.LockResponse{}, nil
+}
+
+func (h *locketHandler) Release(ctx context.Context, req *models.ReleaseRequest) (*models.ReleaseResponse, error) {
+	logger := h.logger.Session("release", lager.Data{"key": req.Resource.Key})
+	h.requestsNotifier.IncrementRequestCount()
+
+	err := h.db.Release(logger, req.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ReleaseResponse{}, nil
+}
+
+func (h *locketHandler) Fetch(ctx context.Context, req *models.FetchRequest) (*models.FetchResponse, error) {
+	logger := h.logger.Session("fetch", lager.Data{"key": req.Key})
+	h.requestsNotifier.IncrementRequestCount()
+
+	resource, err := h.db.Fetch(logger, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FetchResponse{Resource: resource}, nil
+}
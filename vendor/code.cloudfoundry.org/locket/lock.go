@@ -0,0 +1,116 @@
+package locket
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs/guidprovider"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"github.com/hashicorp/consul/api"
+	"github.com/tedsuo/ifrit"
+)
+
+// LeaderKey is the Consul key a Locket instance must hold the session lock
+// on before it is allowed to serve, so only one instance in a cluster ever
+// runs the gRPC server against the shared SQL/etcd backend at a time.
+const LeaderKey = "locket/leader"
+
+type lock struct {
+	logger        lager.Logger
+	consulClient  consuladapter.Client
+	key           string
+	value         string
+	clock         clock.Clock
+	retryInterval time.Duration
+	lockTTL       time.Duration
+}
+
+// NewLock acquires a Consul session-backed key, retrying on retryInterval
+// until it succeeds, then renews the session for as long as Run is not
+// signalled. It mirrors the lock/presence helpers the original Consul-backed
+// locket library offered, repurposed here to elect a single active instance
+// of the gRPC Locket server rather than to lock application-level resources.
+func NewLock(
+	logger lager.Logger,
+	consulClient consuladapter.Client,
+	guidProvider guidprovider.GUIDProvider,
+	key string,
+	clock clock.Clock,
+	retryInterval, lockTTL time.Duration,
+) ifrit.Runner {
+	value := key
+	if guid, err := guidProvider.NextGUID(); err == nil {
+		value = guid
+	}
+
+	return &lock{
+		logger:        logger,
+		consulClient:  consulClient,
+		key:           key,
+		value:         value,
+		clock:         clock,
+		retryInterval: retryInterval,
+		lockTTL:       lockTTL,
+	}
+}
+
+func (l *lock) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := l.logger.Session("lock", lager.Data{"key": l.key})
+
+	sessionID, _, err := l.consulClient.Session().Create(&api.SessionEntry{
+		TTL:      l.lockTTL.String(),
+		Behavior: api.SessionBehaviorRelease,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	acquired := false
+	for !acquired {
+		select {
+		case <-signals:
+			return nil
+		default:
+		}
+
+		acquired, _, err = l.consulClient.KV().Acquire(&api.KVPair{
+			Key:     l.key,
+			Value:   []byte(l.value),
+			Session: sessionID,
+		}, nil)
+		if err != nil {
+			logger.Error("failed-to-acquire-lock", err)
+		}
+
+		if !acquired {
+			timer := l.clock.NewTimer(l.retryInterval)
+			select {
+			case <-signals:
+				timer.Stop()
+				return nil
+			case <-timer.C():
+			}
+		}
+	}
+
+	logger.Info("acquired-lock")
+	close(ready)
+
+	doneCh := make(chan struct{})
+	renewErrCh := make(chan error, 1)
+	go func() {
+		err := l.consulClient.Session().RenewPeriodic(l.lockTTL.String(), sessionID, nil, doneCh)
+		renewErrCh <- err
+	}()
+
+	select {
+	case <-signals:
+		close(doneCh)
+		l.consulClient.Session().Destroy(sessionID, nil)
+		return nil
+	case err := <-renewErrCh:
+		return err
+	}
+}
@@ -0,0 +1,23 @@
+package locket
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"github.com/hashicorp/consul/api"
+	"github.com/tedsuo/ifrit"
+)
+
+const RetryInterval = 5 * time.Second
+
+func NewRegistrationRunner(
+	logger lager.Logger,
+	registration *api.AgentServiceRegistration,
+	consulClient consuladapter.Client,
+	retryInterval time.Duration,
+	clock clock.Clock,
+) ifrit.Runner {
+	return consuladapter.NewServiceRegistrationRunner(logger, consulClient, registration, retryInterval, clock)
+}
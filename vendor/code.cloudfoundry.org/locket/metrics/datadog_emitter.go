@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+const datadogSeriesURL = "https://api.datadoghq.com/api/v1/series"
+
+// datadogEmitter batches SendMetric calls in memory and flushes them to the
+// Datadog HTTP API once per ReportInterval tick, rather than making one HTTP
+// round trip per metric the way a naive adapter would.
+type datadogEmitter struct {
+	logger       lager.Logger
+	clock        clock.Clock
+	apiKey       string
+	appKey       string
+	metricPrefix string
+	httpClient   *http.Client
+
+	mu      sync.Mutex
+	batched map[string]int
+}
+
+func NewDatadogEmitter(logger lager.Logger, clock clock.Clock, reportInterval time.Duration, apiKey, appKey, metricPrefix string) Emitter {
+	e := &datadogEmitter{
+		logger:       logger.Session("datadog-emitter"),
+		clock:        clock,
+		apiKey:       apiKey,
+		appKey:       appKey,
+		metricPrefix: metricPrefix,
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		batched:      map[string]int{},
+	}
+
+	go e.flushPeriodically(reportInterval)
+
+	return e
+}
+
+func (e *datadogEmitter) SendMetric(name string, value int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.batched[name] = value
+	return nil
+}
+
+func (e *datadogEmitter) flushPeriodically(reportInterval time.Duration) {
+	timer := e.clock.NewTimer(reportInterval)
+	defer timer.Stop()
+
+	for range timer.C() {
+		e.flush()
+		timer.Reset(reportInterval)
+	}
+}
+
+type datadogSeries struct {
+	Metric string       `json:"metric"`
+	Points [][2]float64 `json:"points"`
+	Type   string       `json:"type"`
+}
+
+type datadogPayload struct {
+	Series []datadogSeries `json:"series"`
+}
+
+func (e *datadogEmitter) flush() {
+	e.mu.Lock()
+	batched := e.batched
+	e.batched = map[string]int{}
+	e.mu.Unlock()
+
+	if len(batched) == 0 {
+		return
+	}
+
+	now := float64(e.clock.Now().Unix())
+	payload := datadogPayload{}
+	for name, value := range batched {
+		payload.Series = append(payload.Series, datadogSeries{
+			Metric: e.metricPrefix + name,
+			Points: [][2]float64{{now, float64(value)}},
+			Type:   "gauge",
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		e.logger.Error("failed-to-marshal-payload", err)
+		return
+	}
+
+	url := fmt.Sprintf("%s?api_key=%s&application_key=%s", datadogSeriesURL, e.apiKey, e.appKey)
+	resp, err := e.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		e.logger.Error("failed-to-post-metrics", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs/db/sqldb/helpers"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/db"
+)
+
+type DBMetricsNotifier struct {
+	logger         lager.Logger
+	clock          clock.Clock
+	metronClient   Emitter
+	reportInterval time.Duration
+	db             db.LockDB
+	dbMonitor      helpers.QueryMonitor
+}
+
+func NewDBMetricsNotifier(
+	logger lager.Logger,
+	clock clock.Clock,
+	metronClient Emitter,
+	reportInterval time.Duration,
+	db db.LockDB,
+	dbMonitor helpers.QueryMonitor,
+) *DBMetricsNotifier {
+	return &DBMetricsNotifier{
+		logger:         logger,
+		clock:          clock,
+		metronClient:   metronClient,
+		reportInterval: reportInterval,
+		db:             db,
+		dbMonitor:      dbMonitor,
+	}
+}
+
+func (n *DBMetricsNotifier) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := n.logger.Session("db-metrics-notifier")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	timer := n.clock.NewTimer(n.reportInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-timer.C():
+			n.emitMetrics(logger)
+			timer.Reset(n.reportInterval)
+		}
+	}
+}
+
+func (n *DBMetricsNotifier) emitMetrics(logger lager.Logger) {
+	queries, reads, writes := n.dbMonitor.QueryMetrics()
+	n.metronClient.SendMetric("SQLQueries", queries)
+	n.metronClient.SendMetric("SQLReadQueries", reads)
+	n.metronClient.SendMetric("SQLWriteQueries", writes)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+)
+
+//go:generate counterfeiter . Emitter
+
+// Emitter is the narrow surface every notifier in this package needs from a
+// metrics backend, so a single Loggregator client, Datadog client, or a fan
+// out of both can be handed to NewLockMetricsNotifier, NewDBMetricsNotifier,
+// NewRequestMetricsNotifier, NewLockPick, and NewBurglar without those call
+// sites caring which backend is active.
+type Emitter interface {
+	SendMetric(name string, value int) error
+}
+
+type loggregatorEmitter struct {
+	client loggingclient.IngressClient
+}
+
+// NewLoggregatorEmitter adapts the existing Loggregator v2 metron client to
+// the Emitter interface.
+func NewLoggregatorEmitter(client loggingclient.IngressClient) Emitter {
+	return &loggregatorEmitter{client: client}
+}
+
+func (e *loggregatorEmitter) SendMetric(name string, value int) error {
+	return e.client.SendMetric(name, value)
+}
+
+type multiEmitter struct {
+	emitters []Emitter
+}
+
+// NewMultiEmitter fans a single SendMetric call out to every given Emitter,
+// backing the "both" setting of cfg.MetricsEmitter.
+func NewMultiEmitter(emitters ...Emitter) Emitter {
+	return &multiEmitter{emitters: emitters}
+}
+
+func (e *multiEmitter) SendMetric(name string, value int) error {
+	var firstErr error
+	for _, emitter := range e.emitters {
+		if err := emitter.SendMetric(name, value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
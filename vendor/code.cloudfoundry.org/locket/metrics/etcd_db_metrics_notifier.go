@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+// ETCDDBMetricsNotifier polls each cluster member's /v3/maintenance/status
+// endpoint instead of the QueryMonitor the SQL backend uses, since etcd
+// exposes cluster health and DB size there rather than through query counts.
+type ETCDDBMetricsNotifier struct {
+	logger         lager.Logger
+	clock          clock.Clock
+	metronClient   Emitter
+	reportInterval time.Duration
+	clusterUrls    []string
+	httpClient     *http.Client
+}
+
+func NewETCDDBMetricsNotifier(
+	logger lager.Logger,
+	clock clock.Clock,
+	metronClient Emitter,
+	reportInterval time.Duration,
+	clusterUrls []string,
+) *ETCDDBMetricsNotifier {
+	return &ETCDDBMetricsNotifier{
+		logger:         logger,
+		clock:          clock,
+		metronClient:   metronClient,
+		reportInterval: reportInterval,
+		clusterUrls:    clusterUrls,
+		httpClient:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type maintenanceStatus struct {
+	DbSize   int64  `json:"dbSize"`
+	Leader   string `json:"leader"`
+	RaftTerm int64  `json:"raftTerm"`
+}
+
+func (n *ETCDDBMetricsNotifier) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := n.logger.Session("etcd-db-metrics-notifier")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	timer := n.clock.NewTimer(n.reportInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-timer.C():
+			n.emitMetrics(logger)
+			timer.Reset(n.reportInterval)
+		}
+	}
+}
+
+func (n *ETCDDBMetricsNotifier) emitMetrics(logger lager.Logger) {
+	healthyMembers := 0
+	var dbSize int64
+
+	for _, url := range n.clusterUrls {
+		resp, err := n.httpClient.Get(url + "/v3/maintenance/status")
+		if err != nil {
+			logger.Error("failed-to-reach-member", err, lager.Data{"url": url})
+			continue
+		}
+
+		var status maintenanceStatus
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			logger.Error("failed-to-decode-status", err, lager.Data{"url": url})
+			continue
+		}
+
+		healthyMembers++
+		if status.DbSize > dbSize {
+			dbSize = status.DbSize
+		}
+	}
+
+	n.metronClient.SendMetric("ETCDHealthyMembers", healthyMembers)
+	n.metronClient.SendMetric("ETCDDBSize", int(dbSize))
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/locket/db"
+)
+
+type LockMetricsNotifier struct {
+	logger         lager.Logger
+	clock          clock.Clock
+	metronClient   Emitter
+	reportInterval time.Duration
+	db             db.LockDB
+}
+
+func NewLockMetricsNotifier(
+	logger lager.Logger,
+	clock clock.Clock,
+	metronClient Emitter,
+	reportInterval time.Duration,
+	db db.LockDB,
+) *LockMetricsNotifier {
+	return &LockMetricsNotifier{
+		logger:         logger,
+		clock:          clock,
+		metronClient:   metronClient,
+		reportInterval: reportInterval,
+		db:             db,
+	}
+}
+
+func (n *LockMetricsNotifier) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := n.logger.Session("lock-metrics-notifier")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	timer := n.clock.NewTimer(n.reportInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-timer.C():
+			n.emitMetrics(logger)
+			timer.Reset(n.reportInterval)
+		}
+	}
+}
+
+func (n *LockMetricsNotifier) emitMetrics(logger lager.Logger) {
+	locks, err := n.db.Count(logger, "lock")
+	if err != nil {
+		logger.Error("failed-to-count-locks", err)
+		return
+	}
+	n.metronClient.SendMetric("ActiveLocks", locks)
+
+	presences, err := n.db.Count(logger, "presence")
+	if err != nil {
+		logger.Error("failed-to-count-presences", err)
+		return
+	}
+	n.metronClient.SendMetric("ActivePresences", presences)
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+type RequestMetricsNotifier struct {
+	logger         lager.Logger
+	clock          clock.Clock
+	metronClient   Emitter
+	reportInterval time.Duration
+	requestsCount  int64
+}
+
+func NewRequestMetricsNotifier(
+	logger lager.Logger,
+	clock clock.Clock,
+	metronClient Emitter,
+	reportInterval time.Duration,
+) *RequestMetricsNotifier {
+	return &RequestMetricsNotifier{
+		logger:         logger,
+		clock:          clock,
+		metronClient:   metronClient,
+		reportInterval: reportInterval,
+	}
+}
+
+func (n *RequestMetricsNotifier) IncrementRequestCount() {
+	atomic.AddInt64(&n.requestsCount, 1)
+}
+
+func (n *RequestMetricsNotifier) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := n.logger.Session("request-metrics-notifier")
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	close(ready)
+
+	timer := n.clock.NewTimer(n.reportInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-timer.C():
+			count := atomic.SwapInt64(&n.requestsCount, 0)
+			n.metronClient.SendMetric("LocksRequested", int(count))
+			timer.Reset(n.reportInterval)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+type LocketServer interface {
+	Lock(context.Context, *LockRequest) (*LockResponse, error)
+	Release(context.Context, *ReleaseRequest) (*ReleaseResponse, error)
+	Fetch(context.Context, *FetchRequest) (*FetchResponse, error)
+}
+
+func RegisterLocketServer(s *grpc.Server, srv LocketServer) {
+	s.RegisterService(&_Locket_serviceDesc, srv)
+}
+
+func _Locket_Lock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocketServer).Lock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/locket.Locket/Lock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocketServer).Lock(ctx, req.(*LockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Locket_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocketServer).Release(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/locket.Locket/Release",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocketServer).Release(ctx, req.(*ReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Locket_Fetch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocketServer).Fetch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/locket.Locket/Fetch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocketServer).Fetch(ctx, req.(*FetchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Locket_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "locket.Locket",
+	HandlerType: (*LocketServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Lock", Handler: _Locket_Lock_Handler},
+		{MethodName: "Release", Handler: _Locket_Release_Handler},
+		{MethodName: "Fetch", Handler: _Locket_Fetch_Handler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "locket.proto",
+}
@@ -0,0 +1,54 @@
+package models
+
+type Resource struct {
+	Key           string `json:"key"`
+	Owner         string `json:"owner"`
+	Value         string `json:"value"`
+	TypeCode      int32  `json:"type_code"`
+	Type          string `json:"type"`
+	ModifiedIndex int64  `json:"modified_index"`
+
+	// TtlInSeconds is the requested lock's TTL, copied over from
+	// LockRequest by the handler. It is never persisted to a backing
+	// store; LockPick keeps it in memory to know when a lock is due for
+	// expiration.
+	TtlInSeconds int64 `json:"-"`
+}
+
+const (
+	LockType int32 = iota
+	PresenceType
+)
+
+// ResourceTypeCode maps the "lock"/"presence" resourceType strings LockDB's
+// FetchAll/Count take to the TypeCode stored alongside each Resource.
+// Unrecognized strings (including "") fall back to LockType, matching the
+// type the rest of the API defaults new resources to.
+func ResourceTypeCode(resourceType string) int32 {
+	if resourceType == "presence" {
+		return PresenceType
+	}
+
+	return LockType
+}
+
+type LockRequest struct {
+	Resource     *Resource
+	TtlInSeconds int64
+}
+
+type LockResponse struct{}
+
+type ReleaseRequest struct {
+	Resource *Resource
+}
+
+type ReleaseResponse struct{}
+
+type FetchRequest struct {
+	Key string
+}
+
+type FetchResponse struct {
+	Resource *Resource
+}
@@ -0,0 +1,296 @@
+package uaa_go_client
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/uaa-go-client/config"
+	"code.cloudfoundry.org/uaa-go-client/schema"
+)
+
+//go:generate counterfeiter . Client
+
+type Client interface {
+	FetchKey() (string, error)
+	FetchToken(forceUpdate bool) (*schema.Token, error)
+	DecodeToken(uaaToken string, desiredPermissions ...string) error
+}
+
+type client struct {
+	config     *config.Config
+	clock      clock.Clock
+	logger     lager.Logger
+	httpClient *http.Client
+
+	mutex     sync.Mutex
+	cachedKey string
+	token     *schema.Token
+	fetchedAt time.Time
+}
+
+func NewClient(logger lager.Logger, cfg *config.Config, clock clock.Clock) (Client, error) {
+	if cfg.UaaEndpoint == "" {
+		return nil, errors.New("uaa-endpoint-required")
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.SkipVerification}
+	if cfg.CACerts != "" {
+		caCertPool := x509.NewCertPool()
+		if ok := caCertPool.AppendCertsFromPEM([]byte(cfg.CACerts)); !ok {
+			return nil, errors.New("invalid-ca-certs")
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	return &client{
+		config: cfg,
+		clock:  clock,
+		logger: logger,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// FetchKey returns the PEM-encoded public key UAA signs tokens with,
+// fetching and caching it from /token_key on first use. The key is rotated
+// rarely enough that callers should treat a cached value as always valid;
+// DecodeToken re-fetches it only when verification fails against the cache.
+func (c *client) FetchKey() (string, error) {
+	c.mutex.Lock()
+	cached := c.cachedKey
+	c.mutex.Unlock()
+	if cached != "" {
+		return cached, nil
+	}
+
+	return c.fetchKey()
+}
+
+func (c *client) fetchKey() (string, error) {
+	resp, err := c.doWithRetries(func() (*http.Response, error) {
+		return c.httpClient.Get(c.config.UaaEndpoint + "/token_key")
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch-key-failed: unexpected status code %d", resp.StatusCode)
+	}
+
+	var key schema.TokenKey
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	c.cachedKey = key.Value
+	c.mutex.Unlock()
+
+	return key.Value, nil
+}
+
+// FetchToken performs the client_credentials grant for this client's own
+// service account, caching the result until it is within
+// ExpirationBufferInSec of expiring. forceUpdate bypasses the cache, e.g.
+// after a 401 that suggests the cached token was revoked.
+func (c *client) FetchToken(forceUpdate bool) (*schema.Token, error) {
+	c.mutex.Lock()
+	token, fetchedAt := c.token, c.fetchedAt
+	c.mutex.Unlock()
+
+	if !forceUpdate && token != nil {
+		expiresAt := fetchedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+		if c.clock.Now().Before(expiresAt.Add(-time.Duration(c.config.ExpirationBufferInSec) * time.Second)) {
+			return token, nil
+		}
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequest("POST", c.config.UaaEndpoint+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.config.ClientName, c.config.ClientSecret)
+
+	resp, err := c.doWithRetries(func() (*http.Response, error) {
+		return c.httpClient.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch-token-failed: unexpected status code %d", resp.StatusCode)
+	}
+
+	fetched := &schema.Token{}
+	if err := json.NewDecoder(resp.Body).Decode(fetched); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.token = fetched
+	c.fetchedAt = c.clock.Now()
+	c.mutex.Unlock()
+
+	return fetched, nil
+}
+
+type jwtClaims struct {
+	Exp   int64    `json:"exp"`
+	Scope []string `json:"scope"`
+}
+
+// DecodeToken verifies uaaToken's RS256 signature against UAA's published
+// signing key, checks that it has not expired, and confirms it carries at
+// least one of desiredPermissions as a scope. It retries once against a
+// freshly-fetched key so a mid-life UAA key rotation doesn't reject every
+// token issued since.
+func (c *client) DecodeToken(uaaToken string, desiredPermissions ...string) error {
+	key, err := c.FetchKey()
+	if err != nil {
+		return err
+	}
+
+	claims, err := c.verify(uaaToken, key)
+	if err != nil {
+		key, fetchErr := c.fetchKey()
+		if fetchErr != nil {
+			return err
+		}
+
+		claims, err = c.verify(uaaToken, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return checkClaims(claims, c.clock.Now(), desiredPermissions)
+}
+
+// checkClaims applies the expiry and scope rules DecodeToken enforces once
+// signature verification has already passed: claims must not be expired as
+// of now, and must carry at least one of desiredPermissions as a scope.
+func checkClaims(claims *jwtClaims, now time.Time, desiredPermissions []string) error {
+	if claims.Exp > 0 && now.After(time.Unix(claims.Exp, 0)) {
+		return errors.New("token-expired")
+	}
+
+	for _, required := range desiredPermissions {
+		for _, scope := range claims.Scope {
+			if scope == required {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("token-missing-required-scope")
+}
+
+func (c *client) verify(uaaToken, pemKey string) (*jwtClaims, error) {
+	parts := strings.Split(uaaToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed-jwt")
+	}
+
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("invalid-signing-key")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported-signing-key-type")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("invalid-signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &jwtClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (c *client) doWithRetries(do func() (*http.Response, error)) (*http.Response, error) {
+	var lastErr error
+
+	retries := c.config.MaxNumberOfRetries
+	if retries == 0 {
+		retries = 1
+	}
+
+	for attempt := uint32(0); attempt < retries; attempt++ {
+		if attempt > 0 {
+			timer := c.clock.NewTimer(time.Duration(c.config.RetryInterval) * time.Millisecond)
+			<-timer.C()
+		}
+
+		resp, err := do()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+type noOpClient struct{}
+
+func NewNoOpUaaClient() Client {
+	return &noOpClient{}
+}
+
+func (c *noOpClient) FetchKey() (string, error) {
+	return "", nil
+}
+
+func (c *noOpClient) FetchToken(forceUpdate bool) (*schema.Token, error) {
+	return &schema.Token{}, nil
+}
+
+func (c *noOpClient) DecodeToken(uaaToken string, desiredPermissions ...string) error {
+	return nil
+}
@@ -0,0 +1,146 @@
+package uaa_go_client
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+// signedTestJWT builds a minimal RS256-signed JWT carrying the given claims,
+// returning it alongside the PEM-encoded public key it verifies against.
+func signedTestJWT(t *testing.T, claims jwtClaims) (token string, pemKey string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %s", err)
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %s", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	signingInput := header + "." + payload
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %s", err)
+	}
+
+	token = signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %s", err)
+	}
+	pemKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}))
+
+	return token, pemKey
+}
+
+func TestVerifyAcceptsValidSignature(t *testing.T) {
+	token, pemKey := signedTestJWT(t, jwtClaims{Exp: time.Now().Add(time.Hour).Unix(), Scope: []string{"locket.lock"}})
+
+	c := &client{}
+	claims, err := c.verify(token, pemKey)
+	if err != nil {
+		t.Fatalf("expected valid signature to verify, got: %s", err)
+	}
+	if len(claims.Scope) != 1 || claims.Scope[0] != "locket.lock" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestVerifyRejectsTamperedSignature(t *testing.T) {
+	token, pemKey := signedTestJWT(t, jwtClaims{Exp: time.Now().Add(time.Hour).Unix(), Scope: []string{"locket.lock"}})
+
+	// Flip the final character of the payload segment so the signature no
+	// longer matches.
+	parts := splitJWT(t, token)
+	tampered := parts[0] + "." + tamperLastChar(parts[1]) + "." + parts[2]
+
+	c := &client{}
+	if _, err := c.verify(tampered, pemKey); err == nil {
+		t.Error("expected tampered payload to fail signature verification")
+	}
+}
+
+func TestVerifyRejectsWrongKey(t *testing.T) {
+	token, _ := signedTestJWT(t, jwtClaims{Exp: time.Now().Add(time.Hour).Unix()})
+	_, otherKey := signedTestJWT(t, jwtClaims{})
+
+	c := &client{}
+	if _, err := c.verify(token, otherKey); err == nil {
+		t.Error("expected signature verification against an unrelated key to fail")
+	}
+}
+
+func TestCheckClaimsRejectsExpiredToken(t *testing.T) {
+	claims := &jwtClaims{Exp: time.Now().Add(-time.Minute).Unix(), Scope: []string{"locket.lock"}}
+
+	err := checkClaims(claims, time.Now(), []string{"locket.lock"})
+	if err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+}
+
+func TestCheckClaimsAcceptsUnexpiredToken(t *testing.T) {
+	claims := &jwtClaims{Exp: time.Now().Add(time.Hour).Unix(), Scope: []string{"locket.lock"}}
+
+	if err := checkClaims(claims, time.Now(), []string{"locket.lock"}); err != nil {
+		t.Errorf("expected an unexpired, in-scope token to be accepted, got: %s", err)
+	}
+}
+
+func TestCheckClaimsRejectsMissingScope(t *testing.T) {
+	claims := &jwtClaims{Exp: time.Now().Add(time.Hour).Unix(), Scope: []string{"cloud_controller.read"}}
+
+	err := checkClaims(claims, time.Now(), []string{"locket.lock"})
+	if err == nil {
+		t.Error("expected a token missing the required scope to be rejected")
+	}
+}
+
+func splitJWT(t *testing.T, token string) []string {
+	t.Helper()
+
+	var parts []string
+	start := 0
+	for i, r := range token {
+		if r == '.' {
+			parts = append(parts, token[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, token[start:])
+
+	if len(parts) != 3 {
+		t.Fatalf("expected a 3-part JWT, got %d parts", len(parts))
+	}
+	return parts
+}
+
+func tamperLastChar(segment string) string {
+	if segment == "" {
+		return segment
+	}
+
+	runes := []rune(segment)
+	last := runes[len(runes)-1]
+	if last == 'A' {
+		runes[len(runes)-1] = 'B'
+	} else {
+		runes[len(runes)-1] = 'A'
+	}
+	return string(runes)
+}
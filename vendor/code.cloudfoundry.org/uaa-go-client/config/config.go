@@ -0,0 +1,12 @@
+package config
+
+type Config struct {
+	UaaEndpoint           string `json:"uaa_endpoint"`
+	SkipVerification      bool   `json:"skip_verification"`
+	ClientName            string `json:"client_name"`
+	ClientSecret          string `json:"client_secret"`
+	CACerts               string `json:"ca_certs"`
+	MaxNumberOfRetries    uint32 `json:"max_number_of_retries"`
+	RetryInterval         int64  `json:"retry_interval_in_ms"`
+	ExpirationBufferInSec int64  `json:"expiration_buffer_in_sec"`
+}
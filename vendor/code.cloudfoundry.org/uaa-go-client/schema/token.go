@@ -0,0 +1,15 @@
+package schema
+
+type Token struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// TokenKey is UAA's /token_key response: the PEM-encoded public key UAA
+// signs JWTs with, alongside the algorithm it was signed under.
+type TokenKey struct {
+	Kty   string `json:"kty"`
+	Alg   string `json:"alg"`
+	Value string `json:"value"`
+	Kid   string `json:"kid"`
+}